@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"time"
+)
+
+// atomFeed is a minimal Atom 1.0 feed (RFC 4287), just enough to let
+// subscribers follow project changes in a feed reader.
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Link    atomLink    `xml:"link"`
+	Updated atomTime    `xml:"updated"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomEntry struct {
+	Title   string   `xml:"title"`
+	ID      string   `xml:"id"`
+	Link    atomLink `xml:"link"`
+	Updated atomTime `xml:"updated"`
+	Content atomHTML `xml:"content"`
+}
+
+type atomHTML struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",cdata"`
+}
+
+type atomTime time.Time
+
+func (t atomTime) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return e.EncodeElement(time.Time(t).Format(time.RFC3339), start)
+}
+
+// Scan implements sql.Scanner so atomTime can be read directly out of the
+// changes table's t column.
+func (t *atomTime) Scan(v any) error {
+	switch x := v.(type) {
+	case time.Time:
+		*t = atomTime(x)
+		return nil
+	case string:
+		tt, err := time.Parse(time.RFC3339Nano, x)
+		if err != nil {
+			return err
+		}
+		*t = atomTime(tt)
+		return nil
+	default:
+		return fmt.Errorf("atomTime: unsupported Scan type %T", v)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// dispatch parses the leading, non-flag argument as a subcommand name
+// (defaulting to "run" for backwards compatibility with invocations that
+// only pass flags) and runs it.
+func dispatch(ctx context.Context, args []string) error {
+	cmd := "run"
+	if len(args) > 0 && len(args[0]) > 0 && args[0][0] != '-' {
+		cmd = args[0]
+		args = args[1:]
+	}
+
+	switch cmd {
+	case "run":
+		return runCmd(ctx, args)
+	case "install":
+		return installBrowsers()
+	case "diff":
+		return diffCmd(ctx, args)
+	case "serve":
+		return serveCmd(ctx, args)
+	case "stats":
+		return statsCmd(ctx, args)
+	default:
+		return fmt.Errorf("unknown command %q", cmd)
+	}
+}
+
+func runCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	var cfg runConfig
+	var urlsRaw string
+	fs.StringVar(&cfg.dbPath, "db", "data.db", "database file path")
+	fs.StringVar(&urlsRaw, "urls", "", "comma-separated list of project URLs to scrape, otherwise all")
+	fs.StringVar(&cfg.fetcherKind, "fetcher", "http", "page fetcher to use: http or chromium")
+	fs.DurationVar(&cfg.fetchTimeout, "fetch-timeout", 30*time.Second, "per-page navigation timeout for the chromium fetcher")
+	fs.BoolVar(&cfg.since, "since", false, "skip writing a new observation when the content hash is unchanged, just update last_seen")
+	fs.StringVar(&cfg.sitesPath, "sites", "sites.toml", "path to the sites config file listing which site adapters to scrape")
+	fs.StringVar(&cfg.userAgent, "user-agent", "scrapeyourcity (+https://github.com/danp/scrapeyourcity)", "User-Agent sent to sites, including a contact URL")
+	fs.DurationVar(&cfg.crawlDelay, "crawl-delay", time.Second, "minimum delay between requests to the same host, raised by the host's own robots.txt Crawl-delay if longer")
+	fs.IntVar(&cfg.concurrency, "host-concurrency", 1, "maximum concurrent requests to a single host")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if urlsRaw != "" {
+		cfg.onlyURLs = strings.Split(urlsRaw, ",")
+	}
+	return run(ctx, cfg)
+}
+
+func diffCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	var dbPath string
+	fs.StringVar(&dbPath, "db", "data.db", "database file path")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: %s diff [-db path] <project-url>", os.Args[0])
+	}
+	projectURL := fs.Arg(0)
+
+	db, err := sql.Open("sqlite3", "file:"+dbPath+"?_pragma=foreign_keys(1)&mode=ro")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT c.t, c.diff
+		FROM changes c
+		JOIN projects p ON p.id = c.project_id
+		WHERE p.url = ?
+		ORDER BY c.t`, projectURL)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	n := 0
+	for rows.Next() {
+		var t time.Time
+		var diff string
+		if err := rows.Scan(&t, &diff); err != nil {
+			return err
+		}
+		fmt.Printf("=== %s ===\n%s\n", t.Format(time.RFC3339), diff)
+		n++
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if n == 0 {
+		fmt.Printf("no changes recorded for %s\n", projectURL)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/BurntSushi/toml"
+)
+
+// siteConfig is one configured site: which adapter scrapes it, the base
+// URL to scrape it at, and the site identifier to tag its projects with
+// in the database. Site is distinct from Adapter because two configured
+// sites can run the same platform (e.g. two cities both on Shape Your
+// City) and still need to be told apart once their projects share a
+// database.
+type siteConfig struct {
+	Site    string `toml:"site"`
+	Adapter string `toml:"adapter"`
+	BaseURL string `toml:"base_url"`
+	// HomeURL, if set, is the site's own "home" link that shows up
+	// amongst the project listing tiles (Shape Your City sites do this)
+	// and should be filtered out rather than scraped as a project.
+	HomeURL string `toml:"home_url"`
+}
+
+type sitesFile struct {
+	Sites []siteConfig `toml:"sites"`
+}
+
+// loadSites reads the list of sites to scrape from a sites.toml-style
+// config file.
+func loadSites(path string) ([]siteConfig, error) {
+	var f sitesFile
+	if _, err := toml.DecodeFile(path, &f); err != nil {
+		return nil, fmt.Errorf("loading %s: %w", path, err)
+	}
+	if len(f.Sites) == 0 {
+		return nil, fmt.Errorf("%s: no sites configured", path)
+	}
+	for _, s := range f.Sites {
+		if s.Site == "" || s.Adapter == "" || s.BaseURL == "" {
+			return nil, fmt.Errorf("%s: sites entries need site, adapter, and base_url", path)
+		}
+	}
+	return f.Sites, nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/playwright-community/playwright-go"
+)
+
+// fetcher retrieves the HTML for a page. Implementations may be as simple
+// as an HTTP GET or may render the page in a browser first.
+type fetcher interface {
+	fetchHTML(ctx context.Context, u string, waitFor string) (string, error)
+	Close() error
+}
+
+// fetcherConfig configures the fetcher returned by newFetcher.
+type fetcherConfig struct {
+	kind        string
+	timeout     time.Duration
+	userAgent   string
+	crawlDelay  time.Duration
+	concurrency int
+	db          *sql.DB // used by the http fetcher's conditional-GET cache
+}
+
+// newFetcher returns the fetcher named by cfg.kind ("http" or "chromium").
+func newFetcher(ctx context.Context, cfg fetcherConfig) (fetcher, error) {
+	switch cfg.kind {
+	case "", "http":
+		cache, err := newHTTPCache(ctx, cfg.db)
+		if err != nil {
+			return nil, err
+		}
+		polite := newPoliteClient(cfg.userAgent, cfg.crawlDelay, cfg.concurrency, cache)
+		return &httpFetcher{polite: polite}, nil
+	case "chromium":
+		return newChromiumFetcher(cfg.timeout)
+	default:
+		return nil, fmt.Errorf("unknown fetcher %q", cfg.kind)
+	}
+}
+
+// httpFetcher fetches pages with a polite HTTP GET: robots.txt is honored,
+// requests to a host are rate limited and capped, and conditional GETs
+// skip re-processing pages that haven't changed. It works for
+// server-rendered pages but won't see content populated by client-side JS.
+type httpFetcher struct {
+	polite *politeClient
+}
+
+func (f *httpFetcher) fetchHTML(ctx context.Context, u string, waitFor string) (string, error) {
+	return f.polite.get(ctx, u)
+}
+
+func (f *httpFetcher) Close() error { return nil }
+
+// chromiumFetcher renders pages in a headless Chromium instance via
+// playwright-go, for project pages whose content (maps, embedded widgets,
+// dynamic tabs) is populated by client-side JS. The browser and context are
+// shared across fetches for the lifetime of the fetcher.
+type chromiumFetcher struct {
+	pw      *playwright.Playwright
+	browser playwright.Browser
+	context playwright.BrowserContext
+	timeout float64 // milliseconds, per Playwright's Go API
+}
+
+func newChromiumFetcher(timeout time.Duration) (*chromiumFetcher, error) {
+	pw, err := playwright.Run()
+	if err != nil {
+		return nil, fmt.Errorf("starting playwright: %w", err)
+	}
+
+	browser, err := pw.Chromium.Launch()
+	if err != nil {
+		pw.Stop()
+		return nil, fmt.Errorf("launching chromium: %w", err)
+	}
+
+	bctx, err := browser.NewContext()
+	if err != nil {
+		browser.Close()
+		pw.Stop()
+		return nil, fmt.Errorf("creating browser context: %w", err)
+	}
+
+	return &chromiumFetcher{
+		pw:      pw,
+		browser: browser,
+		context: bctx,
+		timeout: float64(timeout.Milliseconds()),
+	}, nil
+}
+
+func (f *chromiumFetcher) fetchHTML(ctx context.Context, u string, waitFor string) (string, error) {
+	page, err := f.context.NewPage()
+	if err != nil {
+		return "", err
+	}
+	defer page.Close()
+
+	if _, err := page.Goto(u, playwright.PageGotoOptions{Timeout: &f.timeout}); err != nil {
+		return "", fmt.Errorf("navigating to %s: %w", u, err)
+	}
+
+	if waitFor != "" {
+		opts := playwright.PageWaitForSelectorOptions{Timeout: &f.timeout}
+		if _, err := page.WaitForSelector(waitFor, opts); err != nil {
+			return "", fmt.Errorf("waiting for %s on %s: %w", waitFor, u, err)
+		}
+	}
+
+	return page.Content()
+}
+
+func (f *chromiumFetcher) Close() error {
+	if err := f.context.Close(); err != nil {
+		return err
+	}
+	if err := f.browser.Close(); err != nil {
+		return err
+	}
+	return f.pw.Stop()
+}
+
+// installBrowsers downloads the browser binaries Playwright needs, so
+// operators can bootstrap a chromium fetcher without leaving Go.
+func installBrowsers() error {
+	return playwright.Install()
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/ncruces/go-sqlite3"
+	_ "github.com/ncruces/go-sqlite3/driver"
+	_ "github.com/ncruces/go-sqlite3/embed"
+)
+
+func init() {
+	sqlite3.AutoExtension(registerFunctions)
+}
+
+// registerFunctions adds wordcount, charcount, and markdown_headings
+// scalar functions to a connection.
+func registerFunctions(c *sqlite3.Conn) error {
+	if err := c.CreateFunction("wordcount", 1, sqlite3.DETERMINISTIC, wordcountFunc); err != nil {
+		return err
+	}
+	if err := c.CreateFunction("charcount", 1, sqlite3.DETERMINISTIC, charcountFunc); err != nil {
+		return err
+	}
+	if err := c.CreateFunction("markdown_headings", 1, sqlite3.DETERMINISTIC, markdownHeadingsFunc); err != nil {
+		return err
+	}
+	return nil
+}
+
+func wordcountFunc(ctx sqlite3.Context, arg ...sqlite3.Value) {
+	ctx.ResultInt(len(strings.Fields(arg[0].Text())))
+}
+
+func charcountFunc(ctx sqlite3.Context, arg ...sqlite3.Value) {
+	ctx.ResultInt(len([]rune(arg[0].Text())))
+}
+
+// markdownHeadingsFunc counts lines that start with a markdown ATX
+// heading marker ("#" through "######").
+func markdownHeadingsFunc(ctx sqlite3.Context, arg ...sqlite3.Value) {
+	n := 0
+	for _, line := range strings.Split(arg[0].Text(), "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			n++
+		}
+	}
+	ctx.ResultInt(n)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+)
+
+// httpCache persists ETag/Last-Modified headers per URL so subsequent
+// fetches can make conditional requests and skip re-downloading (and
+// re-processing) unchanged pages.
+type httpCache struct {
+	db *sql.DB
+}
+
+func newHTTPCache(ctx context.Context, db *sql.DB) (*httpCache, error) {
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS http_cache (url TEXT PRIMARY KEY, etag TEXT, last_modified TEXT)`)
+	if err != nil {
+		return nil, err
+	}
+	return &httpCache{db: db}, nil
+}
+
+// cachedHeaders are the validators from the last successful fetch of a URL.
+type cachedHeaders struct {
+	ETag         string
+	LastModified string
+}
+
+func (c *httpCache) get(ctx context.Context, u string) (*cachedHeaders, error) {
+	var h cachedHeaders
+	err := c.db.QueryRowContext(ctx, `SELECT etag, last_modified FROM http_cache WHERE url = ?`, u).Scan(&h.ETag, &h.LastModified)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
+
+func (c *httpCache) set(ctx context.Context, u, etag, lastModified string) error {
+	_, err := c.db.ExecContext(ctx, `INSERT OR REPLACE INTO http_cache (url, etag, last_modified) VALUES (?, ?, ?)`, u, etag, lastModified)
+	return err
+}
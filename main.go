@@ -4,200 +4,215 @@ import (
 	"context"
 	"crypto/sha256"
 	"database/sql"
-	"flag"
+	"errors"
 	"log"
-	"net/http"
-	"net/url"
 	"os"
 	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	htmltomarkdown "github.com/JohannesKaufmann/html-to-markdown/v2"
 	"github.com/PuerkitoBio/goquery"
 	"github.com/jxskiss/base62"
-	_ "github.com/ncruces/go-sqlite3/driver"
-	_ "github.com/ncruces/go-sqlite3/embed"
-	"github.com/yosssi/gohtml"
 )
 
+// Project is a single project page as scraped from the projects listing
+// and, once fetched, its cleaned-up content.
+type Project struct {
+	Site  string
+	Title string
+	State string
+	URL   string
+
+	HTMLSum  string
+	HTML     string
+	Markdown string
+}
+
 func main() {
 	ctx := context.Background()
 
-	fs := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
-	var dbPath string
-	var urlsRaw string
-	fs.StringVar(&dbPath, "db", "data.db", "database file path")
-	fs.StringVar(&urlsRaw, "urls", "", "comma-separated list of project URLs to scrape, otherwise all")
-	fs.Parse(os.Args[1:])
-
-	var urls []string
-	if urlsRaw != "" {
-		urls = strings.Split(urlsRaw, ",")
-	}
-	if err := run(ctx, dbPath, urls); err != nil {
+	if err := dispatch(ctx, os.Args[1:]); err != nil {
 		log.Fatal(err)
 	}
 }
 
-func run(ctx context.Context, dbPath string, onlyURLs []string) error {
-	db, err := sql.Open("sqlite3", "file:"+dbPath+"?_pragma=foreign_keys(1)")
+// runConfig holds run's flags; it's a struct rather than a long parameter
+// list since runCmd's flags have grown past the point that's readable
+// positionally.
+type runConfig struct {
+	dbPath       string
+	onlyURLs     []string
+	sitesPath    string
+	since        bool
+	fetcherKind  string
+	fetchTimeout time.Duration
+	userAgent    string
+	crawlDelay   time.Duration
+	concurrency  int
+}
+
+func run(ctx context.Context, cfg runConfig) error {
+	db, err := sql.Open("sqlite3", "file:"+cfg.dbPath+"?_pragma=foreign_keys(1)")
 	if err != nil {
 		return err
 	}
 	defer db.Close()
 
-	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS contents (id INTEGER PRIMARY KEY, hash TEXT UNIQUE, html TEXT, markdown TEXT)`)
+	f, err := newFetcher(ctx, fetcherConfig{
+		kind:        cfg.fetcherKind,
+		timeout:     cfg.fetchTimeout,
+		userAgent:   cfg.userAgent,
+		crawlDelay:  cfg.crawlDelay,
+		concurrency: cfg.concurrency,
+		db:          db,
+	})
 	if err != nil {
 		return err
 	}
+	defer f.Close()
 
-	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS projects (id INTEGER PRIMARY KEY, url TEXT UNIQUE, title TEXT, state TEXT)`)
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS contents (id INTEGER PRIMARY KEY, hash TEXT UNIQUE, html TEXT, markdown TEXT)`)
 	if err != nil {
 		return err
 	}
 
-	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS project_observations (id INTEGER PRIMARY KEY, project_id INTEGER REFERENCES projects (id), t DATETIME, content_id INTEGER REFERENCES contents (id))`)
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS projects (id INTEGER PRIMARY KEY, url TEXT UNIQUE, site TEXT, title TEXT, state TEXT)`)
 	if err != nil {
 		return err
 	}
 
-	projectsURL, err := url.Parse("https://www.shapeyourcityhalifax.ca/projects")
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS project_observations (id INTEGER PRIMARY KEY, project_id INTEGER REFERENCES projects (id), first_seen DATETIME, last_seen DATETIME, content_id INTEGER REFERENCES contents (id))`)
 	if err != nil {
 		return err
 	}
-	abs := func(s string) string {
-		rel, err := url.Parse(s)
-		if err != nil {
-			return ""
-		}
-		return projectsURL.ResolveReference(rel).String()
-	}
 
-	sels, err := get(ctx, "https://www.shapeyourcityhalifax.ca/projects", []string{".project-tile"})
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS changes (id INTEGER PRIMARY KEY, project_id INTEGER REFERENCES projects (id), from_content_id INTEGER REFERENCES contents (id), to_content_id INTEGER REFERENCES contents (id), t DATETIME, diff TEXT)`)
 	if err != nil {
 		return err
 	}
 
-	type Project struct {
-		Title string
-		State string
-		URL   string
-
-		HTMLSum  string
-		HTML     string
-		Markdown string
-	}
-	var projects []Project
-	for _, project := range sels[0].EachIter() {
-		p := Project{
-			State: project.AttrOr("data-state", ""),
-			URL:   abs(project.Find("a.project-tile__link").AttrOr("href", "")),
-		}
-		if p.URL == "https://www.shapeyourcityhalifax.ca/shape-your-city-halifax" {
-			continue
-		}
-		if len(onlyURLs) > 0 && !slices.Contains(onlyURLs, p.URL) {
-			continue
-		}
-		projects = append(projects, p)
+	sites, err := loadSites(cfg.sitesPath)
+	if err != nil {
+		return err
 	}
 
-	for i, p := range projects {
-		log.Printf("fetching %v/%v %v", i+1, len(projects), p.URL)
-		sels, err := get(ctx, p.URL, []string{"#yield"})
+	for _, sc := range sites {
+		adapter, err := newSiteAdapter(sc.Adapter, sc.BaseURL, sc.HomeURL, f)
 		if err != nil {
 			return err
 		}
-
-		removes := []string{
-			"#map-layers",
-			"div[data-markers]",
-			"input[name=authenticity_token]",
-			"div.widget_follow_project",
-			"div.widget_related_projects",
-			"#qanda_description_text",
-			"script",
-			".SocialSharing",
-			"[name=a_comment_body]",
-		}
-		for _, s := range removes {
-			sels[0].Find(s).Remove()
-		}
-
-		for _, input := range sels[0].Find("input").EachIter() {
-			input.RemoveAttr("id")
-		}
-		for _, label := range sels[0].Find("label").EachIter() {
-			label.RemoveAttr("for")
-		}
-		for _, a := range sels[0].Find("a").EachIter() {
-			a.SetAttr("href", abs(a.AttrOr("href", "")))
-		}
-		for _, img := range sels[0].Find("img").EachIter() {
-			img.SetAttr("src", abs(img.AttrOr("src", "")))
-		}
-
-		p.Title = sels[0].Find("h1").First().Text()
-		p.HTML, _ = sels[0].Html()
-		p.HTML = gohtml.Format(p.HTML)
-		p.Markdown, err = htmltomarkdown.ConvertString(p.HTML)
-		if err != nil {
+		if err := scrapeSite(ctx, db, adapter, sc.Site, cfg.onlyURLs, cfg.since, cfg.concurrency); err != nil {
 			return err
 		}
+	}
 
-		sum := sha256.Sum224([]byte(p.HTML))
-		p.HTMLSum = base62.EncodeToString(sum[:])
+	return refreshProjectStats(ctx, db)
+}
 
-		err = func() error {
-			tx, err := db.Begin()
-			if err != nil {
-				return err
-			}
-			defer tx.Rollback()
+// scrapeSite lists and fetches every project on one configured site,
+// recording an observation for each. Pages the fetcher reports as
+// unchanged (via a conditional GET 304) are skipped without reprocessing;
+// only their last_seen is bumped. Up to concurrency projects are fetched
+// at once; the fetcher's own politeClient still caps how many of those
+// are in flight to the same host at a time.
+func scrapeSite(ctx context.Context, db *sql.DB, adapter SiteAdapter, site string, onlyURLs []string, since bool, concurrency int) error {
+	projects, err := adapter.ListProjects(ctx)
+	if errors.Is(err, errNotModified) {
+		// The listing page itself hasn't changed, but that says nothing
+		// about whether its projects' own pages have; fetch the projects
+		// already known for this site instead of skipping the site.
+		log.Printf("%s: project listing unchanged, using previously known projects", site)
+		projects, err = projectsForSite(ctx, db, site)
+	}
+	if err != nil {
+		return err
+	}
 
-			_, err = tx.Exec(`INSERT OR REPLACE INTO contents (id, hash, html, markdown) VALUES ((SELECT id FROM contents WHERE hash = ?), ?, ?, ?)`, p.HTMLSum, p.HTMLSum, p.HTML, p.Markdown)
-			if err != nil {
-				return err
-			}
-			_, err = tx.Exec(`INSERT OR REPLACE INTO projects (id, url, title, state) VALUES ((SELECT id FROM projects WHERE url = ?), ?, ?, ?)`, p.URL, p.URL, p.Title, p.State)
-			if err != nil {
-				return err
-			}
+	if len(onlyURLs) > 0 {
+		projects = slices.DeleteFunc(projects, func(p Project) bool {
+			return !slices.Contains(onlyURLs, p.URL)
+		})
+	}
 
-			_, err = tx.Exec(`INSERT INTO project_observations (project_id, t, content_id) VALUES ((SELECT id FROM projects WHERE url = ?), ?, (SELECT id FROM contents WHERE hash = ?))`, p.URL, time.Now(), p.HTMLSum)
-			if err != nil {
-				return err
-			}
+	if concurrency < 1 {
+		concurrency = 1
+	}
 
-			if err := tx.Commit(); err != nil {
-				return err
-			}
-			return nil
-		}()
-		if err != nil {
-			return err
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var firstErr error
+
+	for i, p := range projects {
+		mu.Lock()
+		stop := firstErr != nil
+		mu.Unlock()
+		if stop {
+			break
 		}
 
-		time.Sleep(time.Second)
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int, p Project) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if err := fetchAndRecord(ctx, db, adapter, site, i, len(projects), p, since); err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+			}
+		}(i, p)
 	}
+	wg.Wait()
 
-	return nil
+	return firstErr
 }
 
-func get(ctx context.Context, u string, selectors []string) ([]*goquery.Selection, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+// fetchAndRecord fetches one project's page and records an observation
+// for it (or, if the fetcher reports the page unchanged, just bumps its
+// last_seen). It's split out of scrapeSite so it can safely run
+// concurrently across projects.
+func fetchAndRecord(ctx context.Context, db *sql.DB, adapter SiteAdapter, site string, i, n int, p Project, since bool) error {
+	log.Printf("fetching %v/%v %v", i+1, n, p.URL)
+
+	p.Site = site
+	html, title, state, err := adapter.FetchProject(ctx, p.URL)
+	if errors.Is(err, errNotModified) {
+		log.Printf("%v unchanged, skipping", p.URL)
+		return touchLastSeen(ctx, db, p.URL)
+	}
 	if err != nil {
-		return nil, err
+		return err
+	}
+	p.HTML = html
+	p.Title = title
+	if state != "" {
+		p.State = state
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	p.Markdown, err = htmltomarkdown.ConvertString(p.HTML)
+	if err != nil {
+		return err
+	}
+
+	sum := sha256.Sum224([]byte(p.HTML))
+	p.HTMLSum = base62.EncodeToString(sum[:])
+
+	return recordObservation(ctx, db, p, since)
+}
+
+func get(ctx context.Context, f fetcher, u string, selectors []string, waitFor string) ([]*goquery.Selection, error) {
+	html, err := f.fetchHTML(ctx, u, waitFor)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// recordObservation upserts the project and its fetched content, then
+// records an observation of it. Whenever the content actually changes from
+// what was last observed, a unified diff of the markdown is stored in
+// changes so `scrapeyourcity diff` can show a timeline of what happened to
+// a project.
+//
+// If since is true, consecutive observations with the same content hash
+// collapse into a single project_observations row (first_seen kept,
+// last_seen advanced). If since is false, a new observation row, with
+// first_seen equal to last_seen, is always written, even when the hash is
+// unchanged.
+func recordObservation(ctx context.Context, db *sql.DB, p Project, since bool) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `INSERT OR REPLACE INTO contents (id, hash, html, markdown) VALUES ((SELECT id FROM contents WHERE hash = ?), ?, ?, ?)`, p.HTMLSum, p.HTMLSum, p.HTML, p.Markdown)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `INSERT OR REPLACE INTO projects (id, url, site, title, state) VALUES ((SELECT id FROM projects WHERE url = ?), ?, ?, ?, ?)`, p.URL, p.URL, p.Site, p.Title, p.State)
+	if err != nil {
+		return err
+	}
+
+	var contentID int64
+	if err := tx.QueryRowContext(ctx, `SELECT id FROM contents WHERE hash = ?`, p.HTMLSum).Scan(&contentID); err != nil {
+		return err
+	}
+
+	var lastObsID, lastContentID sql.NullInt64
+	var lastMarkdown sql.NullString
+	err = tx.QueryRowContext(ctx, `
+		SELECT o.id, o.content_id, c.markdown
+		FROM project_observations o
+		JOIN contents c ON c.id = o.content_id
+		WHERE o.project_id = (SELECT id FROM projects WHERE url = ?)
+		ORDER BY o.id DESC LIMIT 1`, p.URL).Scan(&lastObsID, &lastContentID, &lastMarkdown)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	now := time.Now()
+
+	if lastObsID.Valid && lastContentID.Int64 != contentID {
+		diff, err := unifiedMarkdownDiff(lastMarkdown.String, p.Markdown)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `INSERT INTO changes (project_id, from_content_id, to_content_id, t, diff) VALUES ((SELECT id FROM projects WHERE url = ?), ?, ?, ?, ?)`, p.URL, lastContentID.Int64, contentID, now, diff)
+		if err != nil {
+			return err
+		}
+	}
+
+	if since && lastObsID.Valid && lastContentID.Int64 == contentID {
+		_, err = tx.ExecContext(ctx, `UPDATE project_observations SET last_seen = ? WHERE id = ?`, now, lastObsID.Int64)
+		if err != nil {
+			return err
+		}
+	} else {
+		_, err = tx.ExecContext(ctx, `INSERT INTO project_observations (project_id, first_seen, last_seen, content_id) VALUES ((SELECT id FROM projects WHERE url = ?), ?, ?, ?)`, p.URL, now, now, contentID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// projectsForSite returns the projects already known in the database for
+// site, for use when the site's listing page reports itself unchanged and
+// there's nothing fresh to parse it from.
+func projectsForSite(ctx context.Context, db *sql.DB, site string) ([]Project, error) {
+	rows, err := db.QueryContext(ctx, `SELECT url, site, title, state FROM projects WHERE site = ?`, site)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var projects []Project
+	for rows.Next() {
+		var p Project
+		if err := rows.Scan(&p.URL, &p.Site, &p.Title, &p.State); err != nil {
+			return nil, err
+		}
+		projects = append(projects, p)
+	}
+	return projects, rows.Err()
+}
+
+// touchLastSeen advances the last_seen of a project's most recent
+// observation without writing a new one, for pages the fetcher reports as
+// unchanged via a conditional GET.
+func touchLastSeen(ctx context.Context, db *sql.DB, projectURL string) error {
+	_, err := db.ExecContext(ctx, `
+		UPDATE project_observations SET last_seen = ?
+		WHERE id = (
+			SELECT id FROM project_observations
+			WHERE project_id = (SELECT id FROM projects WHERE url = ?)
+			ORDER BY id DESC LIMIT 1
+		)`, time.Now(), projectURL)
+	return err
+}
+
+// unifiedMarkdownDiff renders a unified diff between two versions of a
+// project's markdown, for storage in the changes table.
+func unifiedMarkdownDiff(from, to string) (string, error) {
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(from),
+		B:        difflib.SplitLines(to),
+		FromFile: "before",
+		ToFile:   "after",
+		Context:  3,
+	})
+}
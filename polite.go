@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/temoto/robotstxt"
+)
+
+// errNotModified is returned by politeClient.get when the server answered
+// 304 Not Modified to a conditional request; the caller already has the
+// current content and shouldn't reprocess anything.
+var errNotModified = errors.New("not modified")
+
+// politeClient is an http.Client wrapper that makes long-running scheduled
+// scrapes safe and cheap: it honors robots.txt (including Crawl-delay),
+// caps requests per host to a configurable rate and concurrency, sends
+// conditional GETs from a persisted ETag/Last-Modified cache, and
+// identifies itself with a descriptive User-Agent.
+type politeClient struct {
+	client      *http.Client
+	userAgent   string
+	cache       *httpCache
+	minDelay    time.Duration
+	concurrency int
+
+	mu     sync.Mutex
+	robots map[string]*robotstxt.RobotsData
+	hosts  map[string]*hostState
+}
+
+// hostState tracks per-host pacing: a semaphore capping concurrent
+// requests, and the time of the last request so callers can wait out the
+// host's crawl delay.
+type hostState struct {
+	sem chan struct{}
+
+	mu      sync.Mutex
+	lastReq time.Time
+	delay   time.Duration
+}
+
+func newPoliteClient(userAgent string, minDelay time.Duration, concurrency int, cache *httpCache) *politeClient {
+	return &politeClient{
+		client:      http.DefaultClient,
+		userAgent:   userAgent,
+		cache:       cache,
+		minDelay:    minDelay,
+		concurrency: concurrency,
+		robots:      make(map[string]*robotstxt.RobotsData),
+		hosts:       make(map[string]*hostState),
+	}
+}
+
+// get fetches u, honoring robots.txt, per-host rate limiting and
+// concurrency, and conditional GET. It returns errNotModified if the
+// content hasn't changed since the last fetch.
+func (c *politeClient) get(ctx context.Context, u string) (string, error) {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return "", err
+	}
+
+	allowed, delay, err := c.robotsAllowed(ctx, parsed)
+	if err != nil {
+		return "", err
+	}
+	if !allowed {
+		return "", fmt.Errorf("robots.txt disallows %s", u)
+	}
+
+	hs := c.hostState(parsed.Host, delay)
+	select {
+	case hs.sem <- struct{}{}:
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+	defer func() { <-hs.sem }()
+
+	if err := hs.wait(ctx); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+
+	if cached, err := c.cache.get(ctx, u); err != nil {
+		return "", err
+	} else if cached != nil {
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return "", errNotModified
+	}
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	if etag, lastMod := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified"); etag != "" || lastMod != "" {
+		if err := c.cache.set(ctx, u, etag, lastMod); err != nil {
+			return "", err
+		}
+	}
+
+	return string(b), nil
+}
+
+// wait blocks until the host's crawl delay has elapsed since its last
+// request, then records this request's time.
+func (h *hostState) wait(ctx context.Context) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if wait := h.delay - time.Since(h.lastReq); wait > 0 {
+		t := time.NewTimer(wait)
+		defer t.Stop()
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	h.lastReq = time.Now()
+	return nil
+}
+
+func (c *politeClient) hostState(host string, delay time.Duration) *hostState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	hs, ok := c.hosts[host]
+	if !ok {
+		hs = &hostState{sem: make(chan struct{}, c.concurrency), delay: delay}
+		c.hosts[host] = hs
+	}
+	return hs
+}
+
+// robotsAllowed fetches (and caches) host's robots.txt and reports whether
+// u.Path may be fetched, along with the crawl delay to honor for the host
+// (the greater of c.minDelay and any Crawl-delay directive).
+func (c *politeClient) robotsAllowed(ctx context.Context, u *url.URL) (bool, time.Duration, error) {
+	c.mu.Lock()
+	data, ok := c.robots[u.Host]
+	c.mu.Unlock()
+
+	if !ok {
+		robotsURL := (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/robots.txt"}).String()
+		req, err := http.NewRequestWithContext(ctx, "GET", robotsURL, nil)
+		if err != nil {
+			return false, 0, err
+		}
+		req.Header.Set("User-Agent", c.userAgent)
+
+		resp, err := c.client.Do(req)
+		if err != nil {
+			// Can't reach robots.txt; proceed as if it allowed everything.
+			data, _ = robotstxt.FromStatusAndString(http.StatusNotFound, "")
+		} else {
+			defer resp.Body.Close()
+			data, err = robotstxt.FromResponse(resp)
+			if err != nil {
+				data, _ = robotstxt.FromStatusAndString(http.StatusNotFound, "")
+			}
+		}
+
+		c.mu.Lock()
+		c.robots[u.Host] = data
+		c.mu.Unlock()
+	}
+
+	group := data.FindGroup(c.userAgent)
+	delay := c.minDelay
+	if group.CrawlDelay > delay {
+		delay = group.CrawlDelay
+	}
+	return group.Test(u.Path), delay, nil
+}
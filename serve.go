@@ -0,0 +1,394 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+var templates = template.Must(template.New("").Parse(`
+{{define "index"}}
+<!doctype html>
+<html><head><title>scrapeyourcity</title></head>
+<body>
+<h1>Projects</h1>
+{{range .Sites}}
+<h2>{{.Site}}</h2>
+{{range .States}}
+<h3>{{.State}}</h3>
+<ul>
+{{range .Projects}}<li><a href="/projects/{{.ID}}">{{.Title}}</a></li>
+{{end}}</ul>
+{{end}}
+{{end}}
+</body></html>
+{{end}}
+
+{{define "project"}}
+<!doctype html>
+<html><head><title>{{.Project.Title}}</title>
+<link rel="alternate" type="application/atom+xml" href="/projects/{{.Project.ID}}/feed.atom"></head>
+<body>
+<p><a href="/">&larr; all projects</a></p>
+<h1>{{.Project.Title}}</h1>
+<p>{{.Project.Site}} &middot; {{.Project.State}} &middot; <a href="{{.Project.URL}}">{{.Project.URL}}</a> &middot; <a href="/projects/{{.Project.ID}}/feed.atom">feed</a></p>
+<h2>Latest</h2>
+<pre>{{.Latest}}</pre>
+<h2>Timeline</h2>
+<ul>
+{{range .Observations}}<li>{{.FirstSeen}} &ndash; {{.LastSeen}}
+{{if .DiffFromID}} <a href="/projects/{{$.Project.ID}}/diff?from={{.DiffFromID}}&to={{.ContentID}}">diff</a>{{end}}
+</li>
+{{end}}</ul>
+</body></html>
+{{end}}
+
+{{define "diff"}}
+<!doctype html>
+<html><head><title>diff</title></head>
+<body>
+<p><a href="/projects/{{.ProjectID}}">&larr; back to project</a></p>
+<pre>{{.Diff}}</pre>
+</body></html>
+{{end}}
+`))
+
+func serveCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	var dbPath string
+	var addr string
+	fs.StringVar(&dbPath, "db", "data.db", "database file path")
+	fs.StringVar(&addr, "addr", "localhost:8080", "address to listen on")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	db, err := sql.Open("sqlite3", "file:"+dbPath+"?_pragma=foreign_keys(1)&mode=ro")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	s := &server{db: db}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /", s.index)
+	mux.HandleFunc("GET /projects/{id}", s.project)
+	mux.HandleFunc("GET /projects/{id}/diff", s.diff)
+	mux.HandleFunc("GET /projects/{id}/feed.atom", s.projectFeed)
+	mux.HandleFunc("GET /feed.atom", s.siteFeed)
+
+	return http.ListenAndServe(addr, mux)
+}
+
+// server holds the read-only DB handle shared across requests.
+type server struct {
+	db *sql.DB
+}
+
+type projectRow struct {
+	ID    int64
+	Site  string
+	Title string
+	State string
+	URL   string
+}
+
+// stateGroup is a state's projects, nested under a siteGroup in the index
+// page so that multiple sites can coexist in one database without their
+// projects running together.
+type stateGroup struct {
+	State    string
+	Projects []projectRow
+}
+
+type siteGroup struct {
+	Site   string
+	States []stateGroup
+}
+
+func (s *server) index(w http.ResponseWriter, r *http.Request) {
+	rows, err := s.db.QueryContext(r.Context(), `SELECT id, site, title, state, url FROM projects ORDER BY site, state, title`)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	byState := map[string]map[string][]projectRow{}
+	var sites []string
+	statesBySite := map[string][]string{}
+	for rows.Next() {
+		var p projectRow
+		if err := rows.Scan(&p.ID, &p.Site, &p.Title, &p.State, &p.URL); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if _, ok := byState[p.Site]; !ok {
+			sites = append(sites, p.Site)
+			byState[p.Site] = map[string][]projectRow{}
+		}
+		if _, ok := byState[p.Site][p.State]; !ok {
+			statesBySite[p.Site] = append(statesBySite[p.Site], p.State)
+		}
+		byState[p.Site][p.State] = append(byState[p.Site][p.State], p)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sort.Strings(sites)
+
+	var groups []siteGroup
+	for _, site := range sites {
+		states := statesBySite[site]
+		sort.Strings(states)
+
+		sg := siteGroup{Site: site}
+		for _, st := range states {
+			sg.States = append(sg.States, stateGroup{State: st, Projects: byState[site][st]})
+		}
+		groups = append(groups, sg)
+	}
+
+	data := struct{ Sites []siteGroup }{groups}
+	if err := templates.ExecuteTemplate(w, "index", data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+type observationRow struct {
+	FirstSeen  string
+	LastSeen   string
+	ContentID  int64
+	DiffFromID int64
+}
+
+func (s *server) project(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "bad project id", http.StatusBadRequest)
+		return
+	}
+
+	var p projectRow
+	p.ID = id
+	err = s.db.QueryRowContext(r.Context(), `SELECT site, title, state, url FROM projects WHERE id = ?`, id).Scan(&p.Site, &p.Title, &p.State, &p.URL)
+	if err == sql.ErrNoRows {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var latest string
+	err = s.db.QueryRowContext(r.Context(), `
+		SELECT c.markdown FROM project_observations o
+		JOIN contents c ON c.id = o.content_id
+		WHERE o.project_id = ? ORDER BY o.id DESC LIMIT 1`, id).Scan(&latest)
+	if err != nil && err != sql.ErrNoRows {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	rows, err := s.db.QueryContext(r.Context(), `
+		SELECT first_seen, last_seen, content_id FROM project_observations
+		WHERE project_id = ? ORDER BY id DESC`, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	var obs []observationRow
+	for rows.Next() {
+		var o observationRow
+		if err := rows.Scan(&o.FirstSeen, &o.LastSeen, &o.ContentID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		obs = append(obs, o)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// obs is newest first; DiffFromID on a row should be the content ID
+	// of the chronologically-earlier neighbor (the next one in this
+	// slice), so diff links always render as old -> new.
+	for i := 0; i < len(obs)-1; i++ {
+		if obs[i].ContentID != obs[i+1].ContentID {
+			obs[i].DiffFromID = obs[i+1].ContentID
+		}
+	}
+
+	data := struct {
+		Project      projectRow
+		Latest       string
+		Observations []observationRow
+	}{p, latest, obs}
+	if err := templates.ExecuteTemplate(w, "project", data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (s *server) diff(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "bad project id", http.StatusBadRequest)
+		return
+	}
+	fromID, err := strconv.ParseInt(r.URL.Query().Get("from"), 10, 64)
+	if err != nil {
+		http.Error(w, "bad from", http.StatusBadRequest)
+		return
+	}
+	toID, err := strconv.ParseInt(r.URL.Query().Get("to"), 10, 64)
+	if err != nil {
+		http.Error(w, "bad to", http.StatusBadRequest)
+		return
+	}
+
+	var fromMarkdown, toMarkdown string
+	if err := s.db.QueryRowContext(r.Context(), `SELECT markdown FROM contents WHERE id = ?`, fromID).Scan(&fromMarkdown); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := s.db.QueryRowContext(r.Context(), `SELECT markdown FROM contents WHERE id = ?`, toID).Scan(&toMarkdown); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	d, err := unifiedMarkdownDiff(fromMarkdown, toMarkdown)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	data := struct {
+		ProjectID int64
+		Diff      string
+	}{id, d}
+	if err := templates.ExecuteTemplate(w, "diff", data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (s *server) projectFeed(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "bad project id", http.StatusBadRequest)
+		return
+	}
+
+	var title, url string
+	if err := s.db.QueryRowContext(r.Context(), `SELECT title, url FROM projects WHERE id = ?`, id).Scan(&title, &url); err != nil {
+		if err == sql.ErrNoRows {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	rows, err := s.db.QueryContext(r.Context(), `
+		SELECT id, t, diff FROM changes WHERE project_id = ? ORDER BY t DESC`, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	feed := atomFeed{
+		Title: fmt.Sprintf("%s changes", title),
+		ID:    url,
+		Link:  atomLink{Href: url},
+	}
+	if err := appendChangeEntries(rows, &feed, fmt.Sprintf("/projects/%d", id)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeAtom(w, feed)
+}
+
+func (s *server) siteFeed(w http.ResponseWriter, r *http.Request) {
+	rows, err := s.db.QueryContext(r.Context(), `
+		SELECT c.id, c.t, c.diff, p.title, p.id
+		FROM changes c JOIN projects p ON p.id = c.project_id
+		ORDER BY c.t DESC LIMIT 100`)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	feed := atomFeed{
+		Title: "scrapeyourcity changes",
+		ID:    "/feed.atom",
+		Link:  atomLink{Href: "/feed.atom"},
+	}
+	for rows.Next() {
+		var id int64
+		var t atomTime
+		var diff, title string
+		var projectID int64
+		if err := rows.Scan(&id, &t, &diff, &title, &projectID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		feed.Entries = append(feed.Entries, atomEntry{
+			Title:   fmt.Sprintf("%s changed", title),
+			ID:      fmt.Sprintf("/changes/%d", id),
+			Link:    atomLink{Href: fmt.Sprintf("/projects/%d", projectID)},
+			Updated: t,
+			Content: atomHTML{Type: "html", Body: "<pre>" + diff + "</pre>"},
+		})
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeAtom(w, feed)
+}
+
+// appendChangeEntries reads (id, t, diff) rows into feed's entries, linking
+// each entry back to linkPrefix (the project's page).
+func appendChangeEntries(rows *sql.Rows, feed *atomFeed, linkPrefix string) error {
+	for rows.Next() {
+		var id int64
+		var t atomTime
+		var diff string
+		if err := rows.Scan(&id, &t, &diff); err != nil {
+			return err
+		}
+		feed.Entries = append(feed.Entries, atomEntry{
+			Title:   "change",
+			ID:      fmt.Sprintf("%s/changes/%d", linkPrefix, id),
+			Link:    atomLink{Href: linkPrefix},
+			Updated: t,
+			Content: atomHTML{Type: "html", Body: "<pre>" + diff + "</pre>"},
+		})
+	}
+	return rows.Err()
+}
+
+func writeAtom(w http.ResponseWriter, feed atomFeed) {
+	if len(feed.Entries) > 0 {
+		feed.Updated = feed.Entries[0].Updated
+	}
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	w.Write([]byte(xml.Header))
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	enc.Encode(feed)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/yosssi/gohtml"
+)
+
+// SiteAdapter knows how to list and fetch projects from a particular
+// municipal engagement platform. Different cities run different
+// software (Shape Your City, EngagementHQ, ...) with different listing
+// pages, content containers, and cruft to strip; an adapter encapsulates
+// those differences so the rest of the scraper can stay site-agnostic.
+type SiteAdapter interface {
+	// Name identifies the adapter in sites.toml.
+	Name() string
+	// ListProjects returns the projects currently listed on the site,
+	// with URL and State populated (Title and the content fields are
+	// filled in by FetchProject).
+	ListProjects(ctx context.Context) ([]Project, error)
+	// FetchProject fetches a single project's page and returns its
+	// cleaned HTML, title, and state. An empty state means the caller
+	// should keep whatever state ListProjects already found.
+	FetchProject(ctx context.Context, projectURL string) (html, title, state string, err error)
+	// Clean strips chrome (scripts, share widgets, forms, ...) from a
+	// project page's content container and rewrites relative links.
+	Clean(sel *goquery.Selection)
+}
+
+// newSiteAdapter constructs the adapter named by kind. homeURL, if set, is
+// the site's own home link to exclude from ListProjects results; adapters
+// that don't need it ignore it.
+func newSiteAdapter(kind, baseURL, homeURL string, f fetcher) (SiteAdapter, error) {
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing base URL %q: %w", baseURL, err)
+	}
+
+	switch kind {
+	case "shapeyourcity":
+		return &shapeYourCityAdapter{base: base, homeURL: homeURL, fetcher: f}, nil
+	case "engagementhq":
+		return &engagementHQAdapter{base: base, fetcher: f}, nil
+	default:
+		return nil, fmt.Errorf("unknown site adapter %q", kind)
+	}
+}
+
+// shapeYourCityAdapter scrapes a Shape Your City (Bang the Table) site,
+// e.g. shapeyourcityhalifax.ca.
+type shapeYourCityAdapter struct {
+	base    *url.URL
+	homeURL string
+	fetcher fetcher
+}
+
+func (a *shapeYourCityAdapter) Name() string { return "shapeyourcity" }
+
+func (a *shapeYourCityAdapter) abs(s string) string {
+	rel, err := url.Parse(s)
+	if err != nil {
+		return ""
+	}
+	return a.base.ResolveReference(rel).String()
+}
+
+func (a *shapeYourCityAdapter) ListProjects(ctx context.Context) ([]Project, error) {
+	listURL := a.abs("/projects")
+	sels, err := get(ctx, a.fetcher, listURL, []string{".project-tile"}, ".project-tile")
+	if err != nil {
+		return nil, err
+	}
+
+	var projects []Project
+	for _, tile := range sels[0].EachIter() {
+		p := Project{
+			State: tile.AttrOr("data-state", ""),
+			URL:   a.abs(tile.Find("a.project-tile__link").AttrOr("href", "")),
+		}
+		if p.URL == "" || (a.homeURL != "" && p.URL == a.homeURL) {
+			continue
+		}
+		projects = append(projects, p)
+	}
+	return projects, nil
+}
+
+func (a *shapeYourCityAdapter) FetchProject(ctx context.Context, projectURL string) (string, string, string, error) {
+	sels, err := get(ctx, a.fetcher, projectURL, []string{"#yield"}, "#yield")
+	if err != nil {
+		return "", "", "", err
+	}
+
+	a.Clean(sels[0])
+
+	title := sels[0].Find("h1").First().Text()
+	html, _ := sels[0].Html()
+	html = gohtml.Format(html)
+	return html, title, "", nil
+}
+
+func (a *shapeYourCityAdapter) Clean(sel *goquery.Selection) {
+	removes := []string{
+		"#map-layers",
+		"div[data-markers]",
+		"input[name=authenticity_token]",
+		"div.widget_follow_project",
+		"div.widget_related_projects",
+		"#qanda_description_text",
+		"script",
+		".SocialSharing",
+		"[name=a_comment_body]",
+	}
+	for _, s := range removes {
+		sel.Find(s).Remove()
+	}
+
+	for _, input := range sel.Find("input").EachIter() {
+		input.RemoveAttr("id")
+	}
+	for _, label := range sel.Find("label").EachIter() {
+		label.RemoveAttr("for")
+	}
+	for _, link := range sel.Find("a").EachIter() {
+		link.SetAttr("href", a.abs(link.AttrOr("href", "")))
+	}
+	for _, img := range sel.Find("img").EachIter() {
+		img.SetAttr("src", a.abs(img.AttrOr("src", "")))
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/yosssi/gohtml"
+)
+
+// engagementHQAdapter scrapes an EngagementHQ (Bang the Table) site, a
+// common alternative to Shape Your City used by municipal engagement
+// teams. Its project listing and project pages follow a similar shape but
+// with different selectors and chrome to strip.
+type engagementHQAdapter struct {
+	base    *url.URL
+	fetcher fetcher
+}
+
+func (a *engagementHQAdapter) Name() string { return "engagementhq" }
+
+func (a *engagementHQAdapter) abs(s string) string {
+	rel, err := url.Parse(s)
+	if err != nil {
+		return ""
+	}
+	return a.base.ResolveReference(rel).String()
+}
+
+func (a *engagementHQAdapter) ListProjects(ctx context.Context) ([]Project, error) {
+	listURL := a.abs("/all_projects")
+	sels, err := get(ctx, a.fetcher, listURL, []string{".project-card"}, ".project-card")
+	if err != nil {
+		return nil, err
+	}
+
+	var projects []Project
+	for _, tile := range sels[0].EachIter() {
+		p := Project{
+			State: strings.TrimSpace(tile.Find(".project-card__status").Text()),
+			URL:   a.abs(tile.Find("a.project-card__link").AttrOr("href", "")),
+		}
+		if p.URL == "" {
+			continue
+		}
+		projects = append(projects, p)
+	}
+	return projects, nil
+}
+
+func (a *engagementHQAdapter) FetchProject(ctx context.Context, projectURL string) (string, string, string, error) {
+	sels, err := get(ctx, a.fetcher, projectURL, []string{"#ohq-project-overview"}, "#ohq-project-overview")
+	if err != nil {
+		return "", "", "", err
+	}
+
+	a.Clean(sels[0])
+
+	title := sels[0].Find("h1").First().Text()
+	html, _ := sels[0].Html()
+	html = gohtml.Format(html)
+	return html, title, "", nil
+}
+
+func (a *engagementHQAdapter) Clean(sel *goquery.Selection) {
+	removes := []string{
+		"script",
+		".ohq-share-widget",
+		".ohq-project-sidebar__follow",
+		"#ohq-comments",
+		"input[name=authenticity_token]",
+	}
+	for _, s := range removes {
+		sel.Find(s).Remove()
+	}
+
+	for _, link := range sel.Find("a").EachIter() {
+		link.SetAttr("href", a.abs(link.AttrOr("href", "")))
+	}
+	for _, img := range sel.Find("img").EachIter() {
+		img.SetAttr("src", a.abs(img.AttrOr("src", "")))
+	}
+}
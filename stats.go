@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"time"
+)
+
+// refreshProjectStats rebuilds project_stats, a table of per-project
+// metrics computed over the accumulated observations and contents. SQLite
+// has no materialized views, so it's just dropped and recreated; cheap
+// enough to do at the end of every run.
+func refreshProjectStats(ctx context.Context, db *sql.DB) error {
+	if _, err := db.ExecContext(ctx, `DROP TABLE IF EXISTS project_stats`); err != nil {
+		return err
+	}
+
+	_, err := db.ExecContext(ctx, `
+		CREATE TABLE project_stats AS
+		SELECT
+			p.id AS project_id,
+			p.site,
+			p.url,
+			p.title,
+			wordcount(latest.markdown) AS word_count,
+			charcount(latest.markdown) AS char_count,
+			markdown_headings(latest.markdown) AS heading_count,
+			MIN(o.first_seen) AS first_observed,
+			MAX(o.last_seen) AS last_observed,
+			COUNT(DISTINCT o.content_id) AS revision_count,
+			(JULIANDAY(MAX(o.last_seen)) - JULIANDAY(MIN(o.first_seen))) * 86400.0
+				/ NULLIF(COUNT(DISTINCT o.content_id) - 1, 0) AS avg_seconds_between_changes
+		FROM projects p
+		JOIN project_observations o ON o.project_id = p.id
+		JOIN contents latest ON latest.id = (
+			SELECT content_id FROM project_observations
+			WHERE project_id = p.id ORDER BY id DESC LIMIT 1
+		)
+		GROUP BY p.id`)
+	return err
+}
+
+func statsCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("stats", flag.ContinueOnError)
+	var dbPath string
+	fs.StringVar(&dbPath, "db", "data.db", "database file path")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	db, err := sql.Open("sqlite3", "file:"+dbPath+"?_pragma=foreign_keys(1)&mode=ro")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT site, url, title, word_count, char_count, heading_count,
+			first_observed, last_observed, revision_count, avg_seconds_between_changes
+		FROM project_stats
+		ORDER BY site, title`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var site, url, title string
+		var wordCount, charCount, headingCount, revisionCount int
+		var firstObserved, lastObserved string
+		var avgSecondsBetweenChanges sql.NullFloat64
+		if err := rows.Scan(&site, &url, &title, &wordCount, &charCount, &headingCount,
+			&firstObserved, &lastObserved, &revisionCount, &avgSecondsBetweenChanges); err != nil {
+			return err
+		}
+
+		avg := "n/a"
+		if avgSecondsBetweenChanges.Valid {
+			avg = time.Duration(avgSecondsBetweenChanges.Float64 * float64(time.Second)).String()
+		}
+
+		fmt.Printf("%s: %s (%s)\n", site, title, url)
+		fmt.Printf("  words=%d chars=%d headings=%d revisions=%d\n", wordCount, charCount, headingCount, revisionCount)
+		fmt.Printf("  first_observed=%s last_observed=%s avg_time_between_changes=%s\n", firstObserved, lastObserved, avg)
+	}
+	return rows.Err()
+}